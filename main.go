@@ -1,18 +1,62 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/pengux/pub-sub/pubsub"
 )
 
 func main() {
-	ps := pubsub.New()
+	bindAddr := flag.String("bind-addr", "", "address to bind the cluster gossip protocol to, enabling clustered mode")
+	advertiseAddr := flag.String("advertise-addr", "", "address other cluster nodes should use to reach this node, if different from bind-addr")
+	peers := flag.String("peers", "", "comma-separated addresses of existing cluster nodes to join")
+	storeDir := flag.String("store-dir", "", "directory to persist published messages in a write-ahead log, enabling durable storage; without it, messages are kept in memory only and lost on restart")
+	configPath := flag.String("config", "", "path to a YAML or JSON config file (see pubsub.Config) enabling token authentication, per-identity topic ACLs, and rate limiting; without it, every request is allowed and unthrottled")
+	flag.Parse()
+
+	var opts []pubsub.Option
+	if *storeDir != "" {
+		store, err := pubsub.NewWALStore(*storeDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, pubsub.WithStore(store))
+	}
+	if *configPath != "" {
+		cfg, err := pubsub.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, pubsub.WithConfig(cfg))
+	}
+
+	ps := pubsub.New(opts...)
+
+	if *bindAddr != "" {
+		cluster, err := pubsub.NewCluster(ps, pubsub.ClusterConfig{
+			BindAddr:      *bindAddr,
+			AdvertiseAddr: *advertiseAddr,
+			Peers:         splitPeers(*peers),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		ps.SetCluster(cluster)
+	}
 
 	router := httprouter.New()
 	router = ps.SetupRoutes(router)
 
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
+
+func splitPeers(peers string) []string {
+	if peers == "" {
+		return nil
+	}
+	return strings.Split(peers, ",")
+}
@@ -61,10 +61,10 @@ func TestPubSub(t *testing.T) {
 		t.Errorf("unmarshal message from topic with subscriber1, got %s", err.Error())
 	}
 	if len(msgs) != 1 {
-		t.Errorf("polling messages from topic with subscriber1, expecting message count to be 1 got %s", len(msgs))
+		t.Errorf("polling messages from topic with subscriber1, expecting message count to be 1 got %d", len(msgs))
 	}
-	if msgs[0].Content != message {
-		t.Errorf("polling messages from topic with subscriber1, expecting message to be %s, got %s", message, msgs[0].Content)
+	if string(msgs[0].Payload) != message {
+		t.Errorf("polling messages from topic with subscriber1, expecting message to be %s, got %s", message, msgs[0].Payload)
 	}
 	if msgs[0].Published.IsZero() {
 		t.Errorf("polling messages from topic with subscriber1, expecting published to NOT be zero value")
@@ -80,10 +80,10 @@ func TestPubSub(t *testing.T) {
 		t.Errorf("unmarshal message from topic with subscriber2, got %s", err.Error())
 	}
 	if len(msgs) != 1 {
-		t.Errorf("polling messages from topic with subscriber2, expecting message count to be 1 got %s", len(msgs))
+		t.Errorf("polling messages from topic with subscriber2, expecting message count to be 1 got %d", len(msgs))
 	}
-	if msgs[0].Content != message {
-		t.Errorf("polling messages from topic with subscriber2, expecting message to be %s, got %s", message, msgs[0].Content)
+	if string(msgs[0].Payload) != message {
+		t.Errorf("polling messages from topic with subscriber2, expecting message to be %s, got %s", message, msgs[0].Payload)
 	}
 	if msgs[0].Published.IsZero() {
 		t.Errorf("polling messages from topic with subscriber2, expecting published to NOT be zero value")
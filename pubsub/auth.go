@@ -0,0 +1,192 @@
+package pubsub
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when a request has no or invalid
+// credentials.
+var ErrUnauthenticated = errors.New("pubsub: unauthenticated")
+
+// ErrForbidden is returned when an authenticated identity isn't allowed
+// to publish or subscribe to a topic.
+var ErrForbidden = errors.New("pubsub: forbidden")
+
+// Identity is the result of authenticating a request: who it is, and
+// which topics they may publish to or subscribe to. Patterns are
+// path.Match globs, e.g. "sensors.*"; a whole-segment "*" (or "+") grants
+// one topic segment and "#" grants everything below it, which also lets
+// SubscribePattern requests (see canSubscribePattern) be checked for
+// containment rather than matched as literal text.
+type Identity struct {
+	Name            string
+	PublishTopics   []string
+	SubscribeTopics []string
+}
+
+func (id Identity) canPublish(topic string) bool {
+	return matchesAny(id.PublishTopics, topic)
+}
+
+func (id Identity) canSubscribe(topic string) bool {
+	return matchesAny(id.SubscribeTopics, topic)
+}
+
+// canSubscribePattern reports whether every concrete topic that pattern
+// (the "+"/"#" dialect SubscribePattern accepts) could ever match is
+// itself covered by id.SubscribeTopics. Unlike canSubscribe, this can't
+// delegate to path.Match against pattern's raw text: pattern's own
+// wildcard characters would then be matched as literals instead of
+// expanded, e.g. path.Match("sensors.*", "sensors.#") is true only
+// because "*" happens to match the literal byte '#', not because the
+// ACL actually grants the unbounded subtree "#" asks for.
+func (id Identity) canSubscribePattern(pattern string) bool {
+	for _, acl := range id.SubscribeTopics {
+		if patternWithinACL(acl, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, topic string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// patternWithinACL reports whether every concrete topic matching pattern
+// would also satisfy the path.Match glob acl. It walks both segment by
+// segment: a whole-segment "*" in acl stands for exactly one segment, the
+// same as pattern's own "+", and an acl segment of "#" (not meaningful to
+// path.Match itself, but usable here for identities that need to grant a
+// whole subtree) stands for the rest of the topic, the same as pattern's
+// "#". Anything acl expresses beyond a single wildcard segment - partial
+// globs like "sens*", multi-segment "*" - can't be proven to cover an
+// arbitrary match of pattern, so it's treated as a literal and rejected.
+func patternWithinACL(acl, pattern string) bool {
+	aclSegs := splitTopic(acl)
+	patSegs := splitTopic(pattern)
+
+	for i, patSeg := range patSegs {
+		if patSeg == "#" {
+			return i < len(aclSegs) && aclSegs[i] == "#"
+		}
+		if i >= len(aclSegs) {
+			return false
+		}
+
+		aclSeg := aclSegs[i]
+		switch {
+		case aclSeg == "#":
+			return true
+		case aclSeg == "*" || aclSeg == "+":
+			// Covers any single segment, including pattern's "+".
+		case patSeg != aclSeg:
+			return false
+		}
+	}
+
+	return len(aclSegs) == len(patSegs)
+}
+
+// Authenticator resolves the credentials on a request to an Identity.
+// It should return ErrUnauthenticated when the request has no or
+// invalid credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// WithAuthenticator sets the Authenticator used to authenticate and
+// authorize requests. Without this option, PubSub allows every request,
+// matching pre-auth behavior.
+func WithAuthenticator(a Authenticator) Option {
+	return func(ps *PubSub) {
+		ps.authenticator = a
+	}
+}
+
+// WithRateLimiter sets the RateLimiter used to throttle publishes and
+// polls. Without this option, PubSub doesn't rate limit requests.
+func WithRateLimiter(rl *RateLimiter) Option {
+	return func(ps *PubSub) {
+		ps.rateLimiter = rl
+	}
+}
+
+// credential extracts the bearer token or basic auth password from r's
+// Authorization header.
+func credential(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if token := strings.TrimPrefix(auth, "Bearer "); token != auth {
+		return token, true
+	}
+
+	if _, password, ok := r.BasicAuth(); ok {
+		return password, true
+	}
+
+	return "", false
+}
+
+// remoteIP returns r.RemoteAddr with its ephemeral client port stripped,
+// so unauthenticated requests from the same client share a rate limiter
+// bucket across connections instead of getting a fresh one each time.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authorize authenticates r (when an Authenticator is configured) and
+// checks that the resulting identity may perform aclAction ("publish",
+// "subscribe", or "subscribe_pattern") on topic. "subscribe_pattern"
+// treats topic as a SubscribePattern-style pattern rather than a
+// concrete topic, and checks that every topic it could match is allowed
+// rather than matching the pattern's raw text against the ACL. If
+// rateAction is non-empty ("publish" or "poll"), it also checks that the
+// request isn't rate limited. On failure it writes the appropriate
+// error response and returns false.
+func (ps *PubSub) authorize(w http.ResponseWriter, r *http.Request, topic, aclAction, rateAction string) bool {
+	limiterKey := remoteIP(r)
+
+	if ps.authenticator != nil {
+		id, err := ps.authenticator.Authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return false
+		}
+
+		var allowed bool
+		switch aclAction {
+		case "publish":
+			allowed = id.canPublish(topic)
+		case "subscribe_pattern":
+			allowed = id.canSubscribePattern(topic)
+		default:
+			allowed = id.canSubscribe(topic)
+		}
+		if !allowed {
+			http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+			return false
+		}
+
+		limiterKey = id.Name
+	}
+
+	if rateAction != "" && ps.rateLimiter != nil && !ps.rateLimiter.Allow(limiterKey, topic, rateAction) {
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizeRequiresToken(t *testing.T) {
+	ps := New(WithAuthenticator(newTokenAuthenticator([]TokenConfig{
+		{Token: "secret", Name: "alice", Publish: []string{"news.*"}, Subscribe: []string{"news.*"}},
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/news.sports", nil)
+	if ps.authorize(rec, req, "news.sports", "publish", "publish") {
+		t.Errorf("authorizing without credentials, expecting it to be denied")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("authorizing without credentials, expecting status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestAuthorizeDeniesUnmatchedTopic(t *testing.T) {
+	ps := New(WithAuthenticator(newTokenAuthenticator([]TokenConfig{
+		{Token: "secret", Name: "alice", Publish: []string{"news.*"}},
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/weather.oslo", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if ps.authorize(rec, req, "weather.oslo", "publish", "publish") {
+		t.Errorf("authorizing outside of allowed topics, expecting it to be denied")
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("authorizing outside of allowed topics, expecting status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestAuthorizeAllowsMatchedTopic(t *testing.T) {
+	ps := New(WithAuthenticator(newTokenAuthenticator([]TokenConfig{
+		{Token: "secret", Name: "alice", Publish: []string{"news.*"}},
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/news.sports", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if !ps.authorize(rec, req, "news.sports", "publish", "publish") {
+		t.Errorf("authorizing an allowed topic, expecting it to be allowed, got status %d", rec.Code)
+	}
+}
+
+func TestCanSubscribePatternDeniesBroaderWildcardThanACL(t *testing.T) {
+	id := Identity{SubscribeTopics: []string{"sensors.*"}}
+
+	if id.canSubscribePattern("sensors.#") {
+		t.Errorf("checking pattern sensors.# against ACL sensors.*, expecting it to be denied since # asks for unbounded depth a single * doesn't grant")
+	}
+}
+
+func TestCanSubscribePatternAllowsPatternWithinACL(t *testing.T) {
+	id := Identity{SubscribeTopics: []string{"sensors.*"}}
+
+	if !id.canSubscribePattern("sensors.+") {
+		t.Errorf("checking pattern sensors.+ against ACL sensors.*, expecting it to be allowed since + asks for exactly the single segment * grants")
+	}
+}
+
+func TestCanSubscribePatternDeniesPatternDeeperThanACL(t *testing.T) {
+	id := Identity{SubscribeTopics: []string{"sensors.*"}}
+
+	if id.canSubscribePattern("sensors.+.temp") {
+		t.Errorf("checking pattern sensors.+.temp against ACL sensors.*, expecting it to be denied since the ACL only grants one segment under sensors")
+	}
+}
+
+func TestCanSubscribePatternAllowsHashACLGrantingSubtree(t *testing.T) {
+	id := Identity{SubscribeTopics: []string{"sensors.#"}}
+
+	for _, pattern := range []string{"sensors.#", "sensors.+.temp", "sensors.livingroom.temp"} {
+		if !id.canSubscribePattern(pattern) {
+			t.Errorf("checking pattern %q against ACL sensors.#, expecting it to be allowed", pattern)
+		}
+	}
+}
+
+func TestAuthorizeSubscribePatternDeniesPatternBroaderThanACL(t *testing.T) {
+	ps := New(WithAuthenticator(newTokenAuthenticator([]TokenConfig{
+		{Token: "secret", Name: "alice", Subscribe: []string{"sensors.*"}},
+	})))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/subscribe", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if ps.authorize(rec, req, "sensors.#", "subscribe_pattern", "") {
+		t.Errorf("authorizing sensors.# for an identity scoped to sensors.*, expecting it to be denied")
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("authorizing sensors.# for an identity scoped to sensors.*, expecting status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestRemoteIPStripsPort(t *testing.T) {
+	req := httptest.NewRequest("POST", "/news.sports", nil)
+
+	req.RemoteAddr = "203.0.113.5:54321"
+	if got := remoteIP(req); got != "203.0.113.5" {
+		t.Errorf("stripping port from %q, expecting %q, got %q", req.RemoteAddr, "203.0.113.5", got)
+	}
+
+	req.RemoteAddr = "203.0.113.5:9999"
+	if got := remoteIP(req); got != "203.0.113.5" {
+		t.Errorf("two connections from the same client, expecting the same rate limiter key %q, got %q", "203.0.113.5", got)
+	}
+}
@@ -0,0 +1,215 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// ClusterConfig configures a Cluster.
+type ClusterConfig struct {
+	BindAddr      string
+	BindPort      int
+	AdvertiseAddr string
+	Peers         []string
+}
+
+// Cluster fans a PubSub's published messages out across nodes behind a
+// load balancer. It uses memberlist for peer discovery and failure
+// detection, and a consistent hash over subscriber names to decide which
+// node owns a given subscriber's queue.
+type Cluster struct {
+	ps   *PubSub
+	list *memberlist.Memberlist
+}
+
+// clusterMessage is what's gossiped between nodes when a message is
+// published on one of them.
+type clusterMessage struct {
+	Topic   string  `json:"topic"`
+	Message Message `json:"message"`
+}
+
+// NewCluster starts a cluster node for ps and, if cfg.Peers is set,
+// joins the cluster through them. Publishes made on any node are
+// gossiped to every other node so their local subscribers receive them
+// too.
+func NewCluster(ps *PubSub, cfg ClusterConfig) (*Cluster, error) {
+	c := &Cluster{ps: ps}
+
+	mlConfig := memberlist.DefaultLANConfig()
+	if cfg.BindAddr != "" {
+		mlConfig.BindAddr = cfg.BindAddr
+	}
+	if cfg.BindPort != 0 {
+		mlConfig.BindPort = cfg.BindPort
+	}
+	if cfg.AdvertiseAddr != "" {
+		mlConfig.AdvertiseAddr = cfg.AdvertiseAddr
+	}
+	mlConfig.Delegate = c
+
+	list, err := memberlist.Create(mlConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.list = list
+
+	if len(cfg.Peers) > 0 {
+		if _, err := list.Join(cfg.Peers); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func nodeAddr(n *memberlist.Node) string {
+	return fmt.Sprintf("%s:%d", n.Addr, n.Port)
+}
+
+// nodes returns the current cluster membership in a stable order, so
+// every node computes the same ring.
+func (c *Cluster) nodes() []*memberlist.Node {
+	nodes := c.list.Members()
+	sort.Slice(nodes, func(i, j int) bool { return nodeAddr(nodes[i]) < nodeAddr(nodes[j]) })
+	return nodes
+}
+
+// Members returns the address of every node currently in the cluster.
+func (c *Cluster) Members() []string {
+	nodes := c.nodes()
+	addrs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		addrs = append(addrs, nodeAddr(n))
+	}
+	return addrs
+}
+
+// virtualNodesPerMember is how many points each cluster member gets on
+// the hash ring. Spreading a member across many points keeps the
+// keyspace it owns roughly even and, more importantly, keeps a
+// membership change from remapping much more than the 1/N share that
+// moved to or from the affected member.
+const virtualNodesPerMember = 100
+
+// ring returns the current hash ring as a sorted list of points and the
+// member address each point belongs to.
+func (c *Cluster) ring() ([]uint32, map[uint32]string) {
+	addrs := make([]string, 0, len(c.nodes()))
+	for _, n := range c.nodes() {
+		addrs = append(addrs, nodeAddr(n))
+	}
+
+	return buildRing(addrs)
+}
+
+// buildRing is the pure hashing logic behind Cluster.ring, seamed out so
+// it can be unit tested against a fixed set of addresses instead of a
+// live memberlist cluster. It returns the ring as a sorted list of
+// points and the member address each point belongs to.
+func buildRing(addrs []string) ([]uint32, map[uint32]string) {
+	owner := make(map[uint32]string, len(addrs)*virtualNodesPerMember)
+	for _, addr := range addrs {
+		for i := 0; i < virtualNodesPerMember; i++ {
+			owner[fnv32(fmt.Sprintf("%s#%d", addr, i))] = addr
+		}
+	}
+
+	points := make([]uint32, 0, len(owner))
+	for point := range owner {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	return points, owner
+}
+
+// owner returns the address of the node that owns subscriber: the owner
+// of the ring point nearest clockwise from subscriber's hash, so that a
+// member joining or leaving only remaps the fraction of the keyspace
+// that moved.
+func (c *Cluster) owner(subscriber string) string {
+	points, owner := c.ring()
+	if len(points) == 0 {
+		return nodeAddr(c.list.LocalNode())
+	}
+
+	return ringOwner(points, owner, subscriber)
+}
+
+// ringOwner is the pure lookup logic behind Cluster.owner, seamed out
+// for the same reason as buildRing. points must be sorted ascending and
+// non-empty.
+func ringOwner(points []uint32, owner map[uint32]string, subscriber string) string {
+	h := fnv32(subscriber)
+	idx := sort.Search(len(points), func(i int) bool { return points[i] >= h })
+	if idx == len(points) {
+		idx = 0
+	}
+
+	return owner[points[idx]]
+}
+
+// isLocal reports whether this node owns subscriber.
+func (c *Cluster) isLocal(subscriber string) bool {
+	return c.owner(subscriber) == nodeAddr(c.list.LocalNode())
+}
+
+// redirect writes a 307 redirect to the node that owns subscriber if
+// it's not this node, and reports whether it did so.
+func (c *Cluster) redirect(w http.ResponseWriter, r *http.Request, subscriber string) bool {
+	if c.isLocal(subscriber) {
+		return false
+	}
+
+	target := "http://" + c.owner(subscriber) + r.URL.RequestURI()
+	http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	return true
+}
+
+// broadcast forwards msg to every other node so their local subscribers
+// to topic receive it too.
+func (c *Cluster) broadcast(topic string, msg Message) {
+	payload, err := json.Marshal(clusterMessage{Topic: topic, Message: msg})
+	if err != nil {
+		return
+	}
+
+	self := nodeAddr(c.list.LocalNode())
+	for _, n := range c.nodes() {
+		if nodeAddr(n) == self {
+			continue
+		}
+		c.list.SendReliable(n, payload)
+	}
+}
+
+// NotifyMsg implements memberlist.Delegate. It's called when another
+// node forwards a published message.
+func (c *Cluster) NotifyMsg(data []byte) {
+	var cm clusterMessage
+	if err := json.Unmarshal(data, &cm); err != nil {
+		return
+	}
+	c.ps.deliverLocal(cm.Topic, cm.Message)
+}
+
+func (c *Cluster) NodeMeta(limit int) []byte                  { return nil }
+func (c *Cluster) GetBroadcasts(overhead, limit int) [][]byte { return nil }
+func (c *Cluster) LocalState(join bool) []byte                { return nil }
+func (c *Cluster) MergeRemoteState(buf []byte, join bool)     {}
+
+// fnv32 is a small, dependency-free string hash used for the consistent
+// hash ring.
+func fnv32(s string) uint32 {
+	var h uint32 = 2166136261
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
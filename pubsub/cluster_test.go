@@ -0,0 +1,87 @@
+package pubsub
+
+import "testing"
+
+func TestBuildRingGivesEveryMemberVirtualNodes(t *testing.T) {
+	addrs := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+	points, owner := buildRing(addrs)
+
+	if want := len(addrs) * virtualNodesPerMember; len(points) != want {
+		t.Fatalf("building a ring for %d members, expecting %d points, got %d", len(addrs), want, len(points))
+	}
+
+	counts := make(map[string]int, len(addrs))
+	for _, addr := range owner {
+		counts[addr]++
+	}
+	for _, addr := range addrs {
+		if counts[addr] != virtualNodesPerMember {
+			t.Errorf("member %s, expecting %d virtual nodes, got %d", addr, virtualNodesPerMember, counts[addr])
+		}
+	}
+}
+
+func TestRingOwnerIsStableForTheSameMembership(t *testing.T) {
+	addrs := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+	points, owner := buildRing(addrs)
+
+	first := ringOwner(points, owner, "alice")
+	for i := 0; i < 10; i++ {
+		if got := ringOwner(points, owner, "alice"); got != first {
+			t.Fatalf("looking up the same subscriber's owner repeatedly, expecting %s every time, got %s", first, got)
+		}
+	}
+}
+
+func TestRingOwnerIsAlwaysTheSoleMember(t *testing.T) {
+	points, owner := buildRing([]string{"10.0.0.1:7946"})
+
+	for _, subscriber := range []string{"alice", "bob", "carol"} {
+		if got := ringOwner(points, owner, subscriber); got != "10.0.0.1:7946" {
+			t.Errorf("owner of %q with a single-member ring, expecting the only member, got %s", subscriber, got)
+		}
+	}
+}
+
+func TestRingOwnerDistributesAcrossMembers(t *testing.T) {
+	addrs := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+	points, owner := buildRing(addrs)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		subscriber := string(rune('a' + i%26))
+		seen[ringOwner(points, owner, subscriber+string(rune(i)))] = true
+	}
+
+	if len(seen) != len(addrs) {
+		t.Errorf("hashing many subscribers over %d members, expecting all of them to own something, got %v", len(addrs), seen)
+	}
+}
+
+// TestRingOwnerRemapsOnlyAMinorityOnMembershipChange is the property
+// virtualNodesPerMember exists for: adding a member should only move the
+// roughly 1/N share of keys that land on the new member, not reshuffle
+// everyone.
+func TestRingOwnerRemapsOnlyAMinorityOnMembershipChange(t *testing.T) {
+	before := []string{"10.0.0.1:7946", "10.0.0.2:7946", "10.0.0.3:7946"}
+	after := append(append([]string{}, before...), "10.0.0.4:7946")
+
+	beforePoints, beforeOwner := buildRing(before)
+	afterPoints, afterOwner := buildRing(after)
+
+	const subscribers = 1000
+	moved := 0
+	for i := 0; i < subscribers; i++ {
+		subscriber := string(rune(i))
+		if ringOwner(beforePoints, beforeOwner, subscriber) != ringOwner(afterPoints, afterOwner, subscriber) {
+			moved++
+		}
+	}
+
+	// A perfectly even ring would move exactly 1/len(after) of the
+	// keys; give the hash plenty of slack and just assert it's nowhere
+	// near a full reshuffle.
+	if maxExpected := subscribers / len(after) * 3; moved > maxExpected {
+		t.Errorf("adding a 4th member, expecting at most %d/%d subscribers to move, got %d", maxExpected, subscribers, moved)
+	}
+}
@@ -0,0 +1,161 @@
+package pubsub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// errUnsupportedMediaType is returned when a request's Content-Type or
+// Accept header names an encoding pubsub doesn't support.
+var errUnsupportedMediaType = errors.New("pubsub: unsupported media type")
+
+// wireMessage is the JSON shape of a Message: Payload as a plain string
+// when it's valid UTF-8, base64-encoded otherwise.
+type wireMessage struct {
+	Message   string    `json:"message"`
+	Encoding  string    `json:"encoding,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+	Seq       int64     `json:"seq,omitempty"`
+}
+
+func (m Message) toWire() wireMessage {
+	w := wireMessage{Published: m.Published, Seq: m.Seq}
+	if utf8.Valid(m.Payload) {
+		w.Message = string(m.Payload)
+	} else {
+		w.Message = base64.StdEncoding.EncodeToString(m.Payload)
+		w.Encoding = "base64"
+	}
+	return w
+}
+
+func (w wireMessage) toMessage() (Message, error) {
+	msg := Message{Published: w.Published, Seq: w.Seq}
+	if w.Encoding == "base64" {
+		payload, err := base64.StdEncoding.DecodeString(w.Message)
+		if err != nil {
+			return Message{}, err
+		}
+		msg.Payload = payload
+	} else {
+		msg.Payload = []byte(w.Message)
+	}
+	return msg, nil
+}
+
+// MarshalJSON encodes Payload as a plain string when it's valid UTF-8,
+// falling back to base64 for arbitrary binary payloads.
+func (m Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.toWire())
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var w wireMessage
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	msg, err := w.toMessage()
+	if err != nil {
+		return err
+	}
+	*m = msg
+
+	return nil
+}
+
+// mediaType returns the MIME type of a Content-Type or Accept header,
+// stripped of any parameters (e.g. charset).
+func mediaType(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	t, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return header
+	}
+
+	return t
+}
+
+// decodeMessage reads a Message from the body of r according to its
+// Content-Type: application/json (the default), text/plain and
+// application/octet-stream (the raw body is the payload verbatim), and
+// application/msgpack.
+func decodeMessage(r *http.Request) (Message, error) {
+	if r.Body == nil {
+		return Message{}, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return Message{}, err
+	}
+
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "", "application/json":
+		var msg Message
+		if len(body) == 0 {
+			return msg, nil
+		}
+		err := json.Unmarshal(body, &msg)
+		return msg, err
+	case "text/plain", "application/octet-stream":
+		return Message{Payload: body}, nil
+	case "application/msgpack":
+		var msg Message
+		err := msgpack.Unmarshal(body, &msg)
+		return msg, err
+	default:
+		return Message{}, errUnsupportedMediaType
+	}
+}
+
+// encodeMessages writes msgs to w according to the Accept header of r:
+// application/json (a JSON array, the default), text/plain (one message
+// payload per line), application/x-ndjson (one JSON object per line),
+// and application/msgpack (a single msgpack-encoded array).
+func encodeMessages(w http.ResponseWriter, r *http.Request, msgs []Message) error {
+	switch mediaType(r.Header.Get("Accept")) {
+	case "", "*/*", "application/json":
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(msgs)
+	case "text/plain":
+		w.Header().Set("Content-Type", "text/plain")
+		for _, msg := range msgs {
+			if _, err := fmt.Fprintf(w, "%s\n", msg.Payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "application/x-ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, msg := range msgs {
+			if err := enc.Encode(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "application/msgpack":
+		w.Header().Set("Content-Type", "application/msgpack")
+		body, err := msgpack.Marshal(msgs)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(body)
+		return err
+	default:
+		return errUnsupportedMediaType
+	}
+}
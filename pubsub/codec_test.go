@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMessageRaw(t *testing.T) {
+	req := httptest.NewRequest("POST", "/topic", strings.NewReader("raw payload"))
+	req.Header.Set("Content-Type", "text/plain")
+
+	msg, err := decodeMessage(req)
+	if err != nil {
+		t.Fatalf("decoding raw message, got error %s", err)
+	}
+	if string(msg.Payload) != "raw payload" {
+		t.Errorf("decoding raw message, expecting payload %q, got %q", "raw payload", msg.Payload)
+	}
+}
+
+func TestDecodeMessageUnsupportedMediaType(t *testing.T) {
+	req := httptest.NewRequest("POST", "/topic", strings.NewReader("<xml/>"))
+	req.Header.Set("Content-Type", "application/xml")
+
+	if _, err := decodeMessage(req); err != errUnsupportedMediaType {
+		t.Errorf("decoding unsupported media type, expecting errUnsupportedMediaType, got %v", err)
+	}
+}
+
+func TestEncodeMessagesTextPlain(t *testing.T) {
+	req := httptest.NewRequest("GET", "/topic/sub", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+
+	msgs := []Message{{Payload: []byte("one")}, {Payload: []byte("two")}}
+	if err := encodeMessages(rec, req, msgs); err != nil {
+		t.Fatalf("encoding messages as text/plain, got error %s", err)
+	}
+
+	if got, want := rec.Body.String(), "one\ntwo\n"; got != want {
+		t.Errorf("encoding messages as text/plain, expecting body %q, got %q", want, got)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("encoding messages as text/plain, expecting Content-Type %q, got %q", "text/plain", ct)
+	}
+}
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	msg := Message{Payload: []byte("hello")}
+
+	data, err := msg.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshaling message, got error %s", err)
+	}
+	if !strings.Contains(string(data), `"message":"hello"`) {
+		t.Errorf("marshaling message, expecting plain text message field, got %s", data)
+	}
+
+	var decoded Message
+	if err := decoded.UnmarshalJSON(data); err != nil {
+		t.Fatalf("unmarshaling message, got error %s", err)
+	}
+	if string(decoded.Payload) != "hello" {
+		t.Errorf("unmarshaling message, expecting payload %q, got %q", "hello", decoded.Payload)
+	}
+}
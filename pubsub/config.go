@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TokenConfig grants an identity access to a set of topics, keyed by a
+// bearer token or basic auth password.
+type TokenConfig struct {
+	Token     string   `json:"token" yaml:"token"`
+	Name      string   `json:"name" yaml:"name"`
+	Publish   []string `json:"publish" yaml:"publish"`
+	Subscribe []string `json:"subscribe" yaml:"subscribe"`
+}
+
+// Config is the shape of the YAML/JSON file passed to WithConfig.
+type Config struct {
+	Tokens     []TokenConfig   `json:"tokens" yaml:"tokens"`
+	RateLimits RateLimitConfig `json:"rate_limits" yaml:"rate_limits"`
+}
+
+// LoadConfig reads and parses a Config from path. The format (YAML or
+// JSON) is chosen by the file extension; .json is parsed as JSON,
+// everything else as YAML.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// WithConfig configures authentication and rate limiting from cfg. It's
+// equivalent to building a tokenAuthenticator and RateLimiter by hand
+// and passing them via WithAuthenticator and WithRateLimiter. If cfg has
+// no rate_limits section, rate limiting is left disabled rather than
+// building a RateLimiter that allows zero requests per second.
+func WithConfig(cfg *Config) Option {
+	return func(ps *PubSub) {
+		ps.authenticator = newTokenAuthenticator(cfg.Tokens)
+		if !cfg.RateLimits.isZero() {
+			ps.rateLimiter = NewRateLimiter(cfg.RateLimits)
+		}
+	}
+}
+
+// tokenAuthenticator authenticates requests against a fixed set of
+// tokens loaded from Config.
+type tokenAuthenticator struct {
+	identities map[string]Identity
+	sync.RWMutex
+}
+
+func newTokenAuthenticator(tokens []TokenConfig) *tokenAuthenticator {
+	a := &tokenAuthenticator{identities: make(map[string]Identity, len(tokens))}
+	for _, t := range tokens {
+		a.identities[t.Token] = Identity{
+			Name:            t.Name,
+			PublishTopics:   t.Publish,
+			SubscribeTopics: t.Subscribe,
+		}
+	}
+	return a
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := credential(r)
+	if !ok {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	a.RLock()
+	id, ok := a.identities[token]
+	a.RUnlock()
+	if !ok {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	return id, nil
+}
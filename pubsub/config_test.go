@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigParsesYAML(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+tokens:
+  - token: secret
+    name: alice
+    publish: ["news.*"]
+    subscribe: ["news.*"]
+rate_limits:
+  publishpersecond: 5
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("loading yaml config, got error %s", err)
+	}
+	if len(cfg.Tokens) != 1 || cfg.Tokens[0].Name != "alice" {
+		t.Errorf("loading yaml config, expecting a single token for alice, got %v", cfg.Tokens)
+	}
+	if cfg.RateLimits.PublishPerSecond != 5 {
+		t.Errorf("loading yaml config, expecting PublishPerSecond 5, got %v", cfg.RateLimits.PublishPerSecond)
+	}
+}
+
+func TestLoadConfigParsesJSON(t *testing.T) {
+	path := writeConfig(t, "config.json", `{
+		"tokens": [{"token": "secret", "name": "alice", "publish": ["news.*"]}],
+		"rate_limits": {"PublishPerSecond": 5}
+	}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("loading json config, got error %s", err)
+	}
+	if len(cfg.Tokens) != 1 || cfg.Tokens[0].Name != "alice" {
+		t.Errorf("loading json config, expecting a single token for alice, got %v", cfg.Tokens)
+	}
+	if cfg.RateLimits.PublishPerSecond != 5 {
+		t.Errorf("loading json config, expecting PublishPerSecond 5, got %v", cfg.RateLimits.PublishPerSecond)
+	}
+}
+
+func TestWithConfigWiresAuthenticator(t *testing.T) {
+	cfg := &Config{Tokens: []TokenConfig{{Token: "secret", Name: "alice", Publish: []string{"news.*"}}}}
+
+	ps := New(WithConfig(cfg))
+	if ps.authenticator == nil {
+		t.Fatalf("applying WithConfig, expecting an authenticator to be set")
+	}
+}
+
+func TestWithConfigSkipsRateLimiterOnZeroValue(t *testing.T) {
+	ps := New(WithConfig(&Config{}))
+	if ps.rateLimiter != nil {
+		t.Errorf("applying WithConfig with no rate_limits section, expecting rate limiting to stay disabled, got a RateLimiter")
+	}
+}
+
+func TestWithConfigBuildsRateLimiterWhenConfigured(t *testing.T) {
+	ps := New(WithConfig(&Config{RateLimits: RateLimitConfig{PublishPerSecond: 5}}))
+	if ps.rateLimiter == nil {
+		t.Fatalf("applying WithConfig with a rate_limits section, expecting a RateLimiter to be built")
+	}
+}
+
+// writeConfig writes contents to name under a temporary directory and
+// returns its path.
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing test config, got error %s", err)
+	}
+	return path
+}
@@ -2,74 +2,303 @@ package pubsub
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"errors"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// defaultBufferSize is the number of messages buffered per
+	// subscriber before PublishMessage starts dropping messages for
+	// that subscriber.
+	defaultBufferSize = 64
+
+	// defaultPongWait is how long a WebSocket connection is kept open
+	// without receiving a pong from the client.
+	defaultPongWait = 60 * time.Second
+
+	// sseKeepaliveInterval is how often a comment is written to SSE
+	// streams to keep intermediaries from closing idle connections.
+	sseKeepaliveInterval = 30 * time.Second
+)
+
+// ErrBufferFull is returned when a subscriber's message buffer is full and
+// a new message can't be delivered without blocking the publisher.
+var ErrBufferFull = errors.New("pubsub: subscriber buffer full")
+
+// errNotFound is returned internally when a topic or subscriber doesn't exist.
+var errNotFound = errors.New("pubsub: topic or subscriber not found")
+
 type (
 	PubSub struct {
-		// topics is a nested map containing subscribers and slices
-		// of Message for each topic
-		topics map[string]subscriptions
+		// topics is the in-process routing table used to push
+		// messages to connected subscribers in real time. Durable
+		// state (the message log and subscriber acks) lives in
+		// store instead.
+		topics    map[string]subscriptions
+		store     Store
+		wildcards *topicTrie
+
+		// declined records, per topic, the subscribers who've
+		// explicitly unsubscribed from that concrete topic even though
+		// a wildcard pattern of theirs still matches it, so
+		// materializeWildcardSubscriptions doesn't silently resubscribe
+		// them on the next publish.
+		declined map[string]map[string]struct{}
+
+		bufferSize int
+		pongWait   time.Duration
+		upgrader   websocket.Upgrader
+
+		// authenticator and rateLimiter are nil by default, which
+		// leaves every request allowed and unthrottled.
+		authenticator Authenticator
+		rateLimiter   *RateLimiter
+
+		// cluster is nil unless SetCluster is called, which leaves the
+		// PubSub running standalone.
+		cluster *Cluster
+
 		sync.Mutex
 	}
 
+	// Option configures a PubSub created with New.
+	Option func(*PubSub)
+
 	Message struct {
-		Content   string    `json:"message"`
-		Published time.Time `json:"published,omitempty"`
+		Payload   []byte    `json:"-" msgpack:"payload"`
+		Published time.Time `json:"published,omitempty" msgpack:"published,omitempty"`
+		Seq       int64     `json:"seq,omitempty" msgpack:"seq,omitempty"`
 	}
 
-	subscriptions map[string][]Message
+	subscriptions map[string]*subscriber
+
+	// subscriber holds the pending messages for a single subscription.
+	subscriber struct {
+		messages chan Message
+	}
 )
 
+// WithBufferSize sets the number of messages buffered per subscriber.
+// Once the buffer is full, PublishMessage drops the message for that
+// subscriber instead of blocking.
+func WithBufferSize(n int) Option {
+	return func(ps *PubSub) {
+		ps.bufferSize = n
+	}
+}
+
+// WithPongWait sets how long a WebSocket connection is kept open without
+// receiving a pong from the client.
+func WithPongWait(d time.Duration) Option {
+	return func(ps *PubSub) {
+		ps.pongWait = d
+	}
+}
+
 // New returns a new PubSub instance with a Nats encoded connection
-func New() *PubSub {
-	return &PubSub{topics: make(map[string]subscriptions)}
+func New(opts ...Option) *PubSub {
+	ps := &PubSub{
+		topics:     make(map[string]subscriptions),
+		store:      NewMemStore(),
+		wildcards:  newTopicTrie(),
+		declined:   make(map[string]map[string]struct{}),
+		bufferSize: defaultBufferSize,
+		pongWait:   defaultPongWait,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(ps)
+	}
+
+	return ps
 }
 
+// SetCluster enables clustered mode, redirecting requests for
+// subscribers this node doesn't own and gossiping published messages to
+// the rest of the cluster. Cluster construction needs a *PubSub to
+// forward gossiped messages into, so it's wired in after New rather
+// than through an Option.
+func (ps *PubSub) SetCluster(c *Cluster) {
+	ps.cluster = c
+}
+
+// reservedSubscribeTopic and reservedClusterTopic/reservedClusterSubscriber
+// are topic names SetupRoutes reserves for SubscribePattern/UnsubscribePattern
+// and ClusterMembers respectively. httprouter's tree can't host a literal
+// path like "/subscribe" or "/_cluster/members" alongside the
+// ":topic_name" wildcard at the same depth (a static route and a wildcard
+// route can't share a position), so those routes are dispatched from
+// within the wildcarded handlers below instead of being registered on
+// their own.
+const (
+	reservedSubscribeTopic    = "subscribe"
+	reservedClusterTopic      = "_cluster"
+	reservedClusterSubscriber = "members"
+)
+
 // SetupRoutes maps routes to the PubSub's handlers
 func (ps *PubSub) SetupRoutes(router *httprouter.Router) *httprouter.Router {
-	router.POST("/:topic_name", ps.PublishMessage)
+	router.POST("/:topic_name", ps.postTopic)
 	router.POST("/:topic_name/:subscriber_name", ps.Subscribe)
-	router.DELETE("/:topic_name/:subscriber_name", ps.Unsubscribe)
-	router.GET("/:topic_name/:subscriber_name", ps.GetMessages)
+	router.DELETE("/:topic_name/:subscriber_name", ps.deleteTopic)
+	router.GET("/:topic_name/:subscriber_name", ps.getTopic)
+	router.GET("/:topic_name/:subscriber_name/sse", ps.StreamSSE)
+	router.GET("/:topic_name/:subscriber_name/ws", ps.StreamWS)
 
 	return router
 }
 
+// postTopic dispatches POST /:topic_name to SubscribePattern when
+// topic_name is the reserved "subscribe" path segment, and to
+// PublishMessage otherwise.
+func (ps *PubSub) postTopic(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if p.ByName("topic_name") == reservedSubscribeTopic {
+		ps.SubscribePattern(w, r, p)
+		return
+	}
+	ps.PublishMessage(w, r, p)
+}
+
+// deleteTopic dispatches DELETE /:topic_name/:subscriber_name to
+// UnsubscribePattern when topic_name is the reserved "subscribe" path
+// segment, and to Unsubscribe otherwise.
+func (ps *PubSub) deleteTopic(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if p.ByName("topic_name") == reservedSubscribeTopic {
+		ps.UnsubscribePattern(w, r, p)
+		return
+	}
+	ps.Unsubscribe(w, r, p)
+}
+
+// getTopic dispatches GET /:topic_name/:subscriber_name to ClusterMembers
+// when the path is the reserved "/_cluster/members", and to GetMessages
+// otherwise.
+func (ps *PubSub) getTopic(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if p.ByName("topic_name") == reservedClusterTopic && p.ByName("subscriber_name") == reservedClusterSubscriber {
+		ps.ClusterMembers(w, r, p)
+		return
+	}
+	ps.GetMessages(w, r, p)
+}
+
 // PublishMessage send a message to all subscribers
 // POST /:topic_name
 func (ps *PubSub) PublishMessage(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	topic := p.ByName("topic_name")
 
+	if !ps.authorize(w, r, topic, "publish", "publish") {
+		return
+	}
+
+	msg, status, err := ps.publishLocal(topic, r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if status != http.StatusCreated {
+		w.WriteHeader(status)
+		return
+	}
+
+	// Gossiping to the rest of the cluster is a blocking network call
+	// per peer; it must run after ps.Mutex is released and off the
+	// request goroutine, so a slow or unreachable peer can't stall
+	// every other publish/subscribe/unsubscribe/poll on this node.
+	if ps.cluster != nil {
+		go ps.cluster.broadcast(topic, msg)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// publishLocal decodes, stores, and fans msg out to this node's own
+// subscribers of topic, under ps.Mutex. status is the response status
+// PublishMessage should use: StatusNoContent if topic has no
+// subscribers, StatusCreated on success, or an error status paired with
+// a non-nil err.
+func (ps *PubSub) publishLocal(topic string, r *http.Request) (msg Message, status int, err error) {
 	ps.Lock()
 	defer ps.Unlock()
 
+	ps.materializeWildcardSubscriptions(topic)
+
 	// If there is no subscribers to the topic, just return empty response
 	if _, ok := ps.topics[topic]; !ok {
-		w.WriteHeader(http.StatusNoContent)
-		return
+		return Message{}, http.StatusNoContent, nil
 	}
 
-	var msg Message
-	err := unmarshalBody(r, &msg)
+	msg, err = decodeMessage(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return Message{}, http.StatusBadRequest, err
 	}
 
 	// Set message published time to server's time
 	msg.Published = time.Now()
 
-	for subscriber, _ := range ps.topics[topic] {
-		ps.topics[topic][subscriber] = append(ps.topics[topic][subscriber], msg)
+	seq, err := ps.store.Append(topic, msg)
+	if err != nil {
+		return Message{}, http.StatusInternalServerError, err
 	}
+	msg.Seq = seq
 
-	w.WriteHeader(http.StatusCreated)
+	for _, sub := range ps.topics[topic] {
+		// Fan out without blocking the publisher on a slow
+		// subscriber; ErrBufferFull is dropped on the floor here
+		// since PublishMessage delivers to many subscribers at once.
+		_ = sub.send(msg)
+	}
+
+	return msg, http.StatusCreated, nil
+}
+
+// deliverLocal appends msg to this node's own copy of topic's durable
+// log and fans it out to this node's own subscribers. It's how a node
+// applies a message another cluster node has already published and
+// gossiped over, so a subscriber owned by this node can still replay
+// from the log even though the publish happened elsewhere.
+func (ps *PubSub) deliverLocal(topic string, msg Message) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	ps.materializeWildcardSubscriptions(topic)
+
+	// If there is no local subscriber to the topic, there's nothing to
+	// append to, matching PublishMessage's own no-subscribers path.
+	if _, ok := ps.topics[topic]; !ok {
+		return
+	}
+
+	seq, err := ps.store.Append(topic, msg)
+	if err != nil {
+		return
+	}
+	msg.Seq = seq
+
+	for _, sub := range ps.topics[topic] {
+		_ = sub.send(msg)
+	}
+}
+
+// send delivers msg to the subscriber's buffer without blocking. It
+// returns ErrBufferFull if the buffer is full and the message could not
+// be delivered.
+func (s *subscriber) send(msg Message) error {
+	select {
+	case s.messages <- msg:
+		return nil
+	default:
+		return ErrBufferFull
+	}
 }
 
 // Subscribe adds a subscription to a topic. The subscriber will not receive
@@ -78,24 +307,106 @@ func (ps *PubSub) PublishMessage(w http.ResponseWriter, r *http.Request, p httpr
 func (ps *PubSub) Subscribe(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	topic, subscriber := p.ByName("topic_name"), p.ByName("subscriber_name")
 
+	if !ps.authorize(w, r, topic, "subscribe", "") {
+		return
+	}
+
+	if ps.cluster != nil && ps.cluster.redirect(w, r, subscriber) {
+		return
+	}
+
+	if err := ps.store.Subscribe(topic, subscriber); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 	if _, ok := ps.topics[topic]; !ok {
-		ps.topics[topic] = make(map[string][]Message)
+		ps.topics[topic] = make(subscriptions)
 	}
 
 	if _, ok := ps.topics[topic][subscriber]; !ok {
-		ps.topics[topic][subscriber] = make([]Message, 0)
+		ps.topics[topic][subscriber] = ps.newSubscriber()
 	}
 
+	// An explicit Subscribe overrides any earlier decline of this
+	// concrete topic, so the subscriber can opt back in without having
+	// to touch their wildcard pattern.
+	ps.undecline(topic, subscriber)
+
 	w.WriteHeader(http.StatusCreated)
 }
 
+func (ps *PubSub) newSubscriber() *subscriber {
+	return &subscriber{messages: make(chan Message, ps.bufferSize)}
+}
+
+// materializeWildcardSubscriptions turns every wildcard pattern matching
+// topic into a concrete subscription, so delivery, polling, and
+// unsubscribing behave exactly like a literal Subscribe from here on. A
+// subscriber with several patterns matching the same topic still gets
+// exactly one concrete subscription, since it's keyed on their name.
+//
+// The concrete topic is registered with the store the same way Subscribe
+// does, since a pattern match is often the first time the store has seen
+// this topic at all; skipping that would leave Append rejecting the very
+// publish that triggered the match.
+//
+// Callers must hold ps.Mutex.
+func (ps *PubSub) materializeWildcardSubscriptions(topic string) {
+	for _, name := range ps.wildcards.match(topic) {
+		if _, ok := ps.declined[topic][name]; ok {
+			continue
+		}
+
+		if _, ok := ps.topics[topic]; !ok {
+			ps.topics[topic] = make(subscriptions)
+		}
+		if _, ok := ps.topics[topic][name]; !ok {
+			if err := ps.store.Subscribe(topic, name); err != nil {
+				continue
+			}
+			ps.topics[topic][name] = ps.newSubscriber()
+		}
+	}
+}
+
+// decline records that subscriber explicitly left topic even though a
+// wildcard pattern of theirs still matches it, so
+// materializeWildcardSubscriptions stops resubscribing them to it.
+//
+// Callers must hold ps.Mutex.
+func (ps *PubSub) decline(topic, subscriber string) {
+	if ps.declined[topic] == nil {
+		ps.declined[topic] = make(map[string]struct{})
+	}
+	ps.declined[topic][subscriber] = struct{}{}
+}
+
+// undecline clears a previous decline of topic by subscriber, if any.
+//
+// Callers must hold ps.Mutex.
+func (ps *PubSub) undecline(topic, subscriber string) {
+	delete(ps.declined[topic], subscriber)
+	if len(ps.declined[topic]) == 0 {
+		delete(ps.declined, topic)
+	}
+}
+
 // Unsubscribe removes a subscription of a topic.
 // DELETE /:topic_name/:subscriber_name
 func (ps *PubSub) Unsubscribe(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	topic, subscriber := p.ByName("topic_name"), p.ByName("subscriber_name")
 
+	if !ps.authorize(w, r, topic, "subscribe", "") {
+		return
+	}
+
+	if ps.cluster != nil && ps.cluster.redirect(w, r, subscriber) {
+		return
+	}
+
 	ps.Lock()
 	defer ps.Unlock()
 	if _, ok := ps.topics[topic]; !ok {
@@ -103,11 +414,18 @@ func (ps *PubSub) Unsubscribe(w http.ResponseWriter, r *http.Request, p httprout
 		return
 	}
 
-	if _, ok := ps.topics[topic][subscriber]; !ok {
+	sub, ok := ps.topics[topic][subscriber]
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	if err := ps.store.Unsubscribe(topic, subscriber); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	close(sub.messages)
 	delete(ps.topics[topic], subscriber)
 
 	// If there are no more subscribers left, remove the topic too
@@ -115,6 +433,16 @@ func (ps *PubSub) Unsubscribe(w http.ResponseWriter, r *http.Request, p httprout
 		delete(ps.topics, topic)
 	}
 
+	// If a wildcard pattern of subscriber's still matches topic, their
+	// subscription would otherwise come right back on the next publish;
+	// record that they explicitly left this concrete topic.
+	for _, name := range ps.wildcards.match(topic) {
+		if name == subscriber {
+			ps.decline(topic, subscriber)
+			break
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -123,56 +451,101 @@ func (ps *PubSub) Unsubscribe(w http.ResponseWriter, r *http.Request, p httprout
 func (ps *PubSub) GetMessages(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	topic, subscriber := p.ByName("topic_name"), p.ByName("subscriber_name")
 
-	ps.Lock()
-	defer ps.Unlock()
-	if _, ok := ps.topics[topic]; !ok {
-		w.WriteHeader(http.StatusNotFound)
+	if !ps.authorize(w, r, topic, "subscribe", "poll") {
 		return
 	}
 
-	if _, ok := ps.topics[topic][subscriber]; !ok {
+	if ps.cluster != nil && ps.cluster.redirect(w, r, subscriber) {
+		return
+	}
+
+	sub, err := ps.getSubscriber(topic, subscriber)
+	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	if len(ps.topics[topic][subscriber]) == 0 {
+	var msgs []Message
+	if since := r.URL.Query().Get("since"); since != "" {
+		seq, err := strconv.ParseInt(since, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+
+		msgs, err = ps.store.Read(topic, subscriber, seq, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Advance the subscriber's cursor so the next ?since=0 resumes
+		// after what was just read instead of replaying it again.
+		if len(msgs) > 0 {
+			if err := ps.store.Ack(topic, subscriber, msgs[len(msgs)-1].Seq); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	} else {
+		msgs = drain(sub)
+	}
+
+	if len(msgs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	body, err := json.Marshal(ps.topics[topic][subscriber])
-	if err != nil {
-		http.Error(w, "Could not marshal messages in response", http.StatusInternalServerError)
+	if err := encodeMessages(w, r, msgs); err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
 		return
 	}
+}
 
-	_, err = w.Write(body)
-	if err != nil {
-		http.Error(w, "Could not write body of response", http.StatusInternalServerError)
-		return
+// getSubscriber looks up the subscriber for topic, returning errNotFound if
+// either the topic or the subscriber doesn't exist.
+func (ps *PubSub) getSubscriber(topic, subscriber string) (*subscriber, error) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	if _, ok := ps.topics[topic]; !ok {
+		return nil, errNotFound
 	}
 
-	// empty the message queue for the subscription
-	ps.topics[topic][subscriber] = make([]Message, 0)
+	sub, ok := ps.topics[topic][subscriber]
+	if !ok {
+		return nil, errNotFound
+	}
 
-	w.WriteHeader(http.StatusOK)
+	return sub, nil
 }
 
-// unmarshalBody unmarshal JSON data in body of requests to structs
-func unmarshalBody(r *http.Request, object interface{}) error {
-	if r.Body == nil {
-		return nil
+// ClusterMembers returns the cluster's current ring view, or 404 if
+// this PubSub isn't running in clustered mode.
+// GET /_cluster/members
+func (ps *PubSub) ClusterMembers(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if ps.cluster == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return err
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps.cluster.Members())
+}
 
-	err = json.Unmarshal(body, object)
-	if err != nil {
-		return err
+// drain reads all currently buffered messages off a subscriber without
+// blocking.
+func drain(sub *subscriber) []Message {
+	msgs := make([]Message, 0)
+	for {
+		select {
+		case msg, ok := <-sub.messages:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
 	}
-
-	return nil
 }
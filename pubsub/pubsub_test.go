@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestGetMessagesSinceZeroResumesFromLastAck(t *testing.T) {
+	topic, subscriber := "topic", "alice"
+
+	ps := New()
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", fmt.Sprintf("/%s/%s", topic, subscriber), "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("subscribing, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("POST", fmt.Sprintf("/%s", topic), `{"message": "first"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publishing first message, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("GET", fmt.Sprintf("/%s/%s?since=0", topic, subscriber), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reading with since=0 the first time, expecting status %d, got %d and body %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "first") {
+		t.Fatalf("reading with since=0 the first time, expecting it to include %q, got %s", "first", rec.Body.String())
+	}
+
+	rec = do("POST", fmt.Sprintf("/%s", topic), `{"message": "second"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publishing second message, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("GET", fmt.Sprintf("/%s/%s?since=0", topic, subscriber), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reading with since=0 after an ack, expecting status %d, got %d and body %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "first") {
+		t.Errorf("reading with since=0 after an ack, expecting it to skip the already-read message, got %s", rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "second") {
+		t.Errorf("reading with since=0 after an ack, expecting it to include the new message %q, got %s", "second", rec.Body.String())
+	}
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdleTimeout is how long a per-(action, topic, key) limiter
+	// is kept after its last use before being evicted. Without this, an
+	// attacker could grow RateLimiter.limiters without bound simply by
+	// rotating the key (e.g. its source IP) or topic on every request.
+	limiterIdleTimeout = 10 * time.Minute
+
+	// limiterSweepInterval is how often the idle sweep runs.
+	limiterSweepInterval = time.Minute
+)
+
+// TopicRateLimit overrides the default publish/poll rates for a single
+// topic.
+type TopicRateLimit struct {
+	PublishPerSecond float64
+	PollPerSecond    float64
+}
+
+// RateLimitConfig configures a RateLimiter.
+type RateLimitConfig struct {
+	PublishPerSecond float64
+	PollPerSecond    float64
+	Topics           map[string]TopicRateLimit
+}
+
+// isZero reports whether cfg has no rates configured at all, i.e. what a
+// config file with no rate_limits section decodes to. WithConfig uses
+// this to skip building a RateLimiter in that case: a per-(identity,
+// topic, action) limiter built from this zero value would use
+// rate.Limit(0), which allows exactly one request ever and then returns
+// permanent 429s.
+func (cfg RateLimitConfig) isZero() bool {
+	return cfg.PublishPerSecond == 0 && cfg.PollPerSecond == 0 && len(cfg.Topics) == 0
+}
+
+// RateLimiter throttles publishes and polls with a token bucket per
+// (identity, topic, action), so one noisy identity or topic can't starve
+// the others.
+type RateLimiter struct {
+	cfg      RateLimitConfig
+	limiters map[string]*limiterEntry
+	sync.Mutex
+}
+
+// limiterEntry pairs a limiter with when it was last used, so the
+// idle sweep can evict limiters nobody's used in a while.
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// NewRateLimiter returns a RateLimiter configured by cfg. It starts a
+// background goroutine that evicts limiters idle for longer than
+// limiterIdleTimeout; the goroutine runs until the process exits.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	rl := &RateLimiter{cfg: cfg, limiters: make(map[string]*limiterEntry)}
+	go rl.sweepLoop()
+	return rl
+}
+
+// Allow reports whether a request identified by key may perform action
+// ("publish" or "poll") against topic right now.
+func (rl *RateLimiter) Allow(key, topic, action string) bool {
+	return rl.limiterFor(key, topic, action).Allow()
+}
+
+func (rl *RateLimiter) limiterFor(key, topic, action string) *rate.Limiter {
+	rl.Lock()
+	defer rl.Unlock()
+
+	id := action + "\x00" + topic + "\x00" + key
+	if e, ok := rl.limiters[id]; ok {
+		e.lastUsed = time.Now()
+		return e.limiter
+	}
+
+	perSecond := rl.cfg.PublishPerSecond
+	if action == "poll" {
+		perSecond = rl.cfg.PollPerSecond
+	}
+	if override, ok := rl.cfg.Topics[topic]; ok {
+		if action == "poll" {
+			perSecond = override.PollPerSecond
+		} else {
+			perSecond = override.PublishPerSecond
+		}
+	}
+
+	lim := rate.NewLimiter(rate.Limit(perSecond), burstFor(perSecond))
+	rl.limiters[id] = &limiterEntry{limiter: lim, lastUsed: time.Now()}
+
+	return lim
+}
+
+// sweepLoop periodically evicts idle limiters until stopped.
+func (rl *RateLimiter) sweepLoop() {
+	ticker := time.NewTicker(limiterSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.sweep()
+	}
+}
+
+func (rl *RateLimiter) sweep() {
+	rl.Lock()
+	defer rl.Unlock()
+
+	cutoff := time.Now().Add(-limiterIdleTimeout)
+	for id, e := range rl.limiters {
+		if e.lastUsed.Before(cutoff) {
+			delete(rl.limiters, id)
+		}
+	}
+}
+
+// burstFor picks a burst size proportional to the sustained rate, with a
+// floor of 1 so a configured rate always allows at least one request.
+func burstFor(perSecond float64) int {
+	if perSecond < 1 {
+		return 1
+	}
+	return int(perSecond)
+}
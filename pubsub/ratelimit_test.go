@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{PublishPerSecond: 1})
+
+	if !rl.Allow("alice", "news", "publish") {
+		t.Errorf("first publish, expecting it to be allowed")
+	}
+	if rl.Allow("alice", "news", "publish") {
+		t.Errorf("second immediate publish, expecting it to be rate limited")
+	}
+	if !rl.Allow("bob", "news", "publish") {
+		t.Errorf("a different key's first publish, expecting it to be allowed")
+	}
+}
+
+func TestRateLimiterSweepEvictsIdleLimiters(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{PublishPerSecond: 1})
+
+	rl.Allow("alice", "news", "publish")
+	rl.Allow("bob", "news", "publish")
+
+	rl.Lock()
+	rl.limiters["publish\x00news\x00alice"].lastUsed = time.Now().Add(-limiterIdleTimeout - time.Second)
+	rl.Unlock()
+
+	rl.sweep()
+
+	rl.Lock()
+	_, aliceStillTracked := rl.limiters["publish\x00news\x00alice"]
+	_, bobStillTracked := rl.limiters["publish\x00news\x00bob"]
+	rl.Unlock()
+
+	if aliceStillTracked {
+		t.Errorf("sweeping, expecting the idle limiter to be evicted")
+	}
+	if !bobStillTracked {
+		t.Errorf("sweeping, expecting the recently used limiter to survive")
+	}
+}
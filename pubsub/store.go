@@ -0,0 +1,54 @@
+package pubsub
+
+import "errors"
+
+// ErrUnknownTopic is returned by a Store when an operation references a
+// topic that doesn't exist.
+var ErrUnknownTopic = errors.New("pubsub: unknown topic")
+
+// ErrNotSubscribed is returned by a Store when an operation references a
+// subscriber that isn't subscribed to the given topic.
+var ErrNotSubscribed = errors.New("pubsub: subscriber not subscribed to topic")
+
+// Store persists the durable state of a PubSub: the messages published to
+// each topic and, per subscriber, how far they've read.
+//
+// PubSub itself only keeps the in-process channels used to push messages
+// to connected subscribers in real time (see StreamSSE/StreamWS); the
+// Store is the source of truth for everything that must survive a
+// restart or be shared across implementations.
+type Store interface {
+	// Append writes msg to topic's log, assigning and returning the
+	// next sequence number.
+	Append(topic string, msg Message) (seq int64, err error)
+
+	// Read returns up to limit messages published to topic after
+	// sequence number since. A since of 0 reads from the subscriber's
+	// last acked position instead of the start of the log. A limit of
+	// 0 means no limit. Returns ErrNotSubscribed if subscriber isn't
+	// subscribed to topic.
+	Read(topic, subscriber string, since int64, limit int) ([]Message, error)
+
+	// Ack records that subscriber has consumed every message up to and
+	// including seq upTo.
+	Ack(topic, subscriber string, upTo int64) error
+
+	// Subscribe registers subscriber against topic. It's a no-op if
+	// the subscriber is already subscribed.
+	Subscribe(topic, subscriber string) error
+
+	// Unsubscribe removes subscriber's subscription to topic.
+	Unsubscribe(topic, subscriber string) error
+
+	// Topics returns the name of every topic with at least one
+	// subscriber.
+	Topics() ([]string, error)
+}
+
+// WithStore sets the Store a PubSub delegates its durable state to.
+// Without this option, New uses a MemStore.
+func WithStore(store Store) Option {
+	return func(ps *PubSub) {
+		ps.store = store
+	}
+}
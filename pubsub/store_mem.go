@@ -0,0 +1,139 @@
+package pubsub
+
+import "sync"
+
+// MemStore is an in-memory Store. It's the default used by New and
+// doesn't survive a process restart.
+type MemStore struct {
+	topics map[string]*memTopic
+	sync.Mutex
+}
+
+type memTopic struct {
+	messages []Message
+	// cursors holds, per subscriber, the sequence number of the last
+	// message they've acked.
+	cursors map[string]int64
+}
+
+// NewMemStore returns a new, empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{topics: make(map[string]*memTopic)}
+}
+
+func (s *MemStore) Append(topic string, msg Message) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		return 0, ErrUnknownTopic
+	}
+
+	msg.Seq = int64(len(t.messages)) + 1
+	t.messages = append(t.messages, msg)
+
+	return msg.Seq, nil
+}
+
+func (s *MemStore) Read(topic, subscriber string, since int64, limit int) ([]Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		return nil, ErrUnknownTopic
+	}
+
+	cursor, ok := t.cursors[subscriber]
+	if !ok {
+		return nil, ErrNotSubscribed
+	}
+
+	from := since
+	if from == 0 {
+		from = cursor
+	}
+
+	msgs := make([]Message, 0)
+	for _, msg := range t.messages {
+		if msg.Seq <= from {
+			continue
+		}
+
+		msgs = append(msgs, msg)
+		if limit > 0 && len(msgs) >= limit {
+			break
+		}
+	}
+
+	return msgs, nil
+}
+
+func (s *MemStore) Ack(topic, subscriber string, upTo int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		return ErrUnknownTopic
+	}
+
+	if _, ok := t.cursors[subscriber]; !ok {
+		return ErrNotSubscribed
+	}
+
+	t.cursors[subscriber] = upTo
+
+	return nil
+}
+
+func (s *MemStore) Subscribe(topic, subscriber string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		t = &memTopic{cursors: make(map[string]int64)}
+		s.topics[topic] = t
+	}
+
+	if _, ok := t.cursors[subscriber]; !ok {
+		t.cursors[subscriber] = 0
+	}
+
+	return nil
+}
+
+func (s *MemStore) Unsubscribe(topic, subscriber string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		return ErrUnknownTopic
+	}
+
+	if _, ok := t.cursors[subscriber]; !ok {
+		return ErrNotSubscribed
+	}
+
+	delete(t.cursors, subscriber)
+	if len(t.cursors) == 0 {
+		delete(s.topics, topic)
+	}
+
+	return nil
+}
+
+func (s *MemStore) Topics() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	topics := make([]string, 0, len(s.topics))
+	for topic := range s.topics {
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
@@ -0,0 +1,51 @@
+package pubsub
+
+import "testing"
+
+func TestMemStore(t *testing.T) {
+	s := NewMemStore()
+
+	if err := s.Subscribe("topic", "sub1"); err != nil {
+		t.Fatalf("subscribing, got error %s", err)
+	}
+
+	seq, err := s.Append("topic", Message{Payload: []byte("hello")})
+	if err != nil {
+		t.Fatalf("appending, got error %s", err)
+	}
+	if seq != 1 {
+		t.Errorf("appending, expecting seq 1, got %d", seq)
+	}
+
+	msgs, err := s.Read("topic", "sub1", 0, 0)
+	if err != nil {
+		t.Fatalf("reading, got error %s", err)
+	}
+	if len(msgs) != 1 || string(msgs[0].Payload) != "hello" {
+		t.Errorf("reading, expecting [hello], got %v", msgs)
+	}
+
+	if err := s.Ack("topic", "sub1", seq); err != nil {
+		t.Fatalf("acking, got error %s", err)
+	}
+
+	msgs, err = s.Read("topic", "sub1", 0, 0)
+	if err != nil {
+		t.Fatalf("reading after ack, got error %s", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("reading after ack, expecting no messages, got %v", msgs)
+	}
+
+	if _, err := s.Read("topic", "unknown-sub", 0, 0); err != ErrNotSubscribed {
+		t.Errorf("reading with unknown subscriber, expecting ErrNotSubscribed, got %v", err)
+	}
+
+	if err := s.Unsubscribe("topic", "sub1"); err != nil {
+		t.Fatalf("unsubscribing, got error %s", err)
+	}
+
+	if topics, _ := s.Topics(); len(topics) != 0 {
+		t.Errorf("topics after last unsubscribe, expecting none, got %v", topics)
+	}
+}
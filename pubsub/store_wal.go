@@ -0,0 +1,340 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tidwall/wal"
+)
+
+const (
+	// defaultMaxAge is how long a message is retained in a topic's log
+	// before it becomes eligible for compaction.
+	defaultMaxAge = 24 * time.Hour
+
+	// defaultMaxMessages is how many messages are retained in a
+	// topic's log before the oldest ones are compacted away.
+	defaultMaxMessages = 10000
+
+	// compactInterval is how often the background compactor runs.
+	compactInterval = 5 * time.Minute
+)
+
+// RetentionPolicy bounds how long messages are kept in a topic's durable
+// log.
+type RetentionPolicy struct {
+	MaxAge      time.Duration
+	MaxMessages int
+}
+
+// WALStoreOption configures a WALStore created with NewWALStore.
+type WALStoreOption func(*WALStore)
+
+// WithRetention sets the retention policy applied by the background
+// compactor to every topic's log.
+func WithRetention(policy RetentionPolicy) WALStoreOption {
+	return func(s *WALStore) {
+		s.retention = policy
+	}
+}
+
+// WALStore is a Store backed by a per-topic write-ahead log on disk, so
+// published messages survive a restart. Subscriber cursors are kept in
+// memory, so a subscriber that never reconnects after a restart resumes
+// reading from the start of the log.
+type WALStore struct {
+	dir       string
+	retention RetentionPolicy
+
+	logs map[string]*walTopic
+	subs map[string]map[string]int64 // topic -> subscriber -> acked seq
+	sync.Mutex
+}
+
+type walTopic struct {
+	wal     *wal.Log
+	nextSeq int64
+	sync.Mutex
+}
+
+// NewWALStore returns a WALStore that keeps its per-topic logs under dir.
+func NewWALStore(dir string, opts ...WALStoreOption) (*WALStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &WALStore{
+		dir:  dir,
+		logs: make(map[string]*walTopic),
+		subs: make(map[string]map[string]int64),
+		retention: RetentionPolicy{
+			MaxAge:      defaultMaxAge,
+			MaxMessages: defaultMaxMessages,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.compactLoop()
+
+	return s, nil
+}
+
+func (s *WALStore) getOrCreateLog(topic string) (*walTopic, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if t, ok := s.logs[topic]; ok {
+		return t, nil
+	}
+
+	w, err := wal.Open(filepath.Join(s.dir, topic), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nextSeq := int64(0)
+	if last, err := w.LastIndex(); err == nil {
+		nextSeq = int64(last)
+	}
+
+	t := &walTopic{wal: w, nextSeq: nextSeq}
+	s.logs[topic] = t
+
+	return t, nil
+}
+
+func (s *WALStore) Append(topic string, msg Message) (int64, error) {
+	t, err := s.getOrCreateLog(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	seq := t.nextSeq + 1
+	msg.Seq = seq
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := t.wal.Write(uint64(seq), data); err != nil {
+		return 0, err
+	}
+	t.nextSeq = seq
+
+	return seq, nil
+}
+
+func (s *WALStore) Read(topic, subscriber string, since int64, limit int) ([]Message, error) {
+	s.Lock()
+	cursors, ok := s.subs[topic]
+	if !ok {
+		s.Unlock()
+		return nil, ErrUnknownTopic
+	}
+	cursor, ok := cursors[subscriber]
+	s.Unlock()
+	if !ok {
+		return nil, ErrNotSubscribed
+	}
+
+	t, err := s.getOrCreateLog(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	from := since
+	if from == 0 {
+		from = cursor
+	}
+
+	first, err := t.wal.FirstIndex()
+	if err != nil {
+		return nil, err
+	}
+	last, err := t.wal.LastIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	start := uint64(from) + 1
+	if start < first {
+		start = first
+	}
+
+	msgs := make([]Message, 0)
+	for idx := start; idx <= last; idx++ {
+		data, err := t.wal.Read(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, msg)
+		if limit > 0 && len(msgs) >= limit {
+			break
+		}
+	}
+
+	return msgs, nil
+}
+
+func (s *WALStore) Ack(topic, subscriber string, upTo int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	cursors, ok := s.subs[topic]
+	if !ok {
+		return ErrUnknownTopic
+	}
+	if _, ok := cursors[subscriber]; !ok {
+		return ErrNotSubscribed
+	}
+
+	cursors[subscriber] = upTo
+
+	return nil
+}
+
+func (s *WALStore) Subscribe(topic, subscriber string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	cursors, ok := s.subs[topic]
+	if !ok {
+		cursors = make(map[string]int64)
+		s.subs[topic] = cursors
+	}
+
+	if _, ok := cursors[subscriber]; !ok {
+		cursors[subscriber] = 0
+	}
+
+	return nil
+}
+
+func (s *WALStore) Unsubscribe(topic, subscriber string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	cursors, ok := s.subs[topic]
+	if !ok {
+		return ErrUnknownTopic
+	}
+	if _, ok := cursors[subscriber]; !ok {
+		return ErrNotSubscribed
+	}
+
+	delete(cursors, subscriber)
+	if len(cursors) == 0 {
+		delete(s.subs, topic)
+	}
+
+	return nil
+}
+
+func (s *WALStore) Topics() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	topics := make([]string, 0, len(s.subs))
+	for topic := range s.subs {
+		topics = append(topics, topic)
+	}
+
+	return topics, nil
+}
+
+// compactLoop periodically trims every topic's log down to the
+// configured retention policy until stopped.
+func (s *WALStore) compactLoop() {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.compactAll()
+	}
+}
+
+func (s *WALStore) compactAll() {
+	s.Lock()
+	logs := make([]*walTopic, 0, len(s.logs))
+	for _, t := range s.logs {
+		logs = append(logs, t)
+	}
+	s.Unlock()
+
+	for _, t := range logs {
+		s.compact(t)
+	}
+}
+
+// compact trims t down to the configured max age and max message count,
+// oldest messages first.
+func (s *WALStore) compact(t *walTopic) {
+	t.Lock()
+	defer t.Unlock()
+
+	first, err := t.wal.FirstIndex()
+	if err != nil {
+		return
+	}
+	last, err := t.wal.LastIndex()
+	if err != nil {
+		return
+	}
+
+	truncateTo := first
+
+	if s.retention.MaxMessages > 0 {
+		count := int64(last-first) + 1
+		if over := count - int64(s.retention.MaxMessages); over > 0 {
+			if candidate := first + uint64(over); candidate > truncateTo {
+				truncateTo = candidate
+			}
+		}
+	}
+
+	if s.retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.retention.MaxAge)
+		for idx := first; idx <= last; idx++ {
+			data, err := t.wal.Read(idx)
+			if err != nil {
+				break
+			}
+
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				break
+			}
+
+			if msg.Published.After(cutoff) {
+				break
+			}
+
+			if idx+1 > truncateTo {
+				truncateTo = idx + 1
+			}
+		}
+	}
+
+	if truncateTo > first && truncateTo <= last+1 {
+		t.wal.TruncateFront(truncateTo)
+	}
+}
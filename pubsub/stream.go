@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+)
+
+// StreamSSE streams published messages to the subscriber as
+// Server-Sent Events until the client disconnects.
+// GET /:topic_name/:subscriber_name/sse
+func (ps *PubSub) StreamSSE(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	topic, subscriber := p.ByName("topic_name"), p.ByName("subscriber_name")
+
+	if !ps.authorize(w, r, topic, "subscribe", "poll") {
+		return
+	}
+
+	if ps.cluster != nil && ps.cluster.redirect(w, r, subscriber) {
+		return
+	}
+
+	sub, err := ps.getSubscriber(topic, subscriber)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.messages:
+			if !ok {
+				return
+			}
+
+			body, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "data: %s\n\n", body)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// StreamWS upgrades the connection to a WebSocket and streams published
+// messages to the subscriber as JSON text frames until the client
+// disconnects.
+// GET /:topic_name/:subscriber_name/ws
+func (ps *PubSub) StreamWS(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	topic, subscriber := p.ByName("topic_name"), p.ByName("subscriber_name")
+
+	if !ps.authorize(w, r, topic, "subscribe", "poll") {
+		return
+	}
+
+	if ps.cluster != nil && ps.cluster.redirect(w, r, subscriber) {
+		return
+	}
+
+	sub, err := ps.getSubscriber(topic, subscriber)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	conn, err := ps.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(ps.pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(ps.pongWait))
+		return nil
+	})
+
+	// The client isn't expected to send anything, but we still need to
+	// read from the connection to process control frames (pongs) and
+	// notice when it closes.
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	pingPeriod := ps.pongWait * 9 / 10
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.messages:
+			if !ok {
+				return
+			}
+
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
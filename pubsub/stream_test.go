@@ -0,0 +1,202 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestSubscriberSendDropsWhenBufferFull(t *testing.T) {
+	ps := New(WithBufferSize(1))
+	sub := ps.newSubscriber()
+
+	if err := sub.send(Message{Payload: []byte("first")}); err != nil {
+		t.Fatalf("sending into an empty buffer, got error %s", err)
+	}
+	if err := sub.send(Message{Payload: []byte("second")}); err != ErrBufferFull {
+		t.Errorf("sending into a full buffer, expecting ErrBufferFull, got %v", err)
+	}
+}
+
+func TestStreamSSEDeliversPublishedMessages(t *testing.T) {
+	topic, subscriber := "topic", "sub1"
+
+	ps := New()
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	subscribe(t, server.URL, topic, subscriber)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/"+topic+"/"+subscriber+"/sse", nil)
+	if err != nil {
+		t.Fatalf("building sse request, got error %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("streaming sse, got error %s", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("streaming sse, expecting Content-Type %q, got %q", "text/event-stream", ct)
+	}
+
+	publish(t, server.URL, topic, `{"message": "hello"}`)
+
+	line := readLine(t, resp.Body, 2*time.Second)
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("streaming sse, expecting a data event, got %q", line)
+	}
+	if !strings.Contains(line, "hello") {
+		t.Errorf("streaming sse, expecting the published message in the event, got %q", line)
+	}
+}
+
+func TestStreamWSDeliversPublishedMessages(t *testing.T) {
+	topic, subscriber := "topic", "sub1"
+
+	ps := New()
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	subscribe(t, server.URL, topic, subscriber)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + topic + "/" + subscriber + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dialing websocket, got error %s", err)
+	}
+	defer conn.Close()
+
+	publish(t, server.URL, topic, `{"message": "hello"}`)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading websocket message, got error %s", err)
+	}
+	if string(msg.Payload) != "hello" {
+		t.Errorf("reading websocket message, expecting payload %q, got %q", "hello", msg.Payload)
+	}
+}
+
+func TestStreamWSPingKeepsConnectionAlive(t *testing.T) {
+	topic, subscriber := "topic", "sub1"
+
+	ps := New(WithPongWait(100 * time.Millisecond))
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	subscribe(t, server.URL, topic, subscriber)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/" + topic + "/" + subscriber + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dialing websocket, got error %s", err)
+	}
+	defer conn.Close()
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteMessage(websocket.PongMessage, nil)
+	})
+
+	// A short PongWait makes StreamWS's ping ticker (90% of it) fire
+	// quickly; gorilla/websocket only invokes the ping handler above
+	// while a read is in flight, so keep one running in the background.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("waiting for a ping frame from StreamWS, timed out")
+	}
+}
+
+func subscribe(t *testing.T, baseURL, topic, subscriber string) {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/"+topic+"/"+subscriber, "", nil)
+	if err != nil {
+		t.Fatalf("subscribing, got error %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("subscribing, expecting status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func publish(t *testing.T, baseURL, topic, body string) {
+	t.Helper()
+
+	resp, err := http.Post(baseURL+"/"+topic, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("publishing, got error %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("publishing, expecting status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+// readLine reads a single non-blank line from r, failing the test if
+// none arrives within timeout.
+func readLine(t *testing.T, r io.Reader, timeout time.Duration) string {
+	t.Helper()
+
+	lines := make(chan string, 1)
+	errs := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				errs <- err
+				return
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			lines <- line
+			return
+		}
+	}()
+
+	select {
+	case line := <-lines:
+		return line
+	case err := <-errs:
+		t.Fatalf("reading stream, got error %s", err)
+	case <-time.After(timeout):
+		t.Fatalf("reading stream, timed out after %s", timeout)
+	}
+	return ""
+}
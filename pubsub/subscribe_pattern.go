@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// SubscribePattern registers subscriber against a hierarchical topic
+// pattern. "+" matches exactly one segment and "#" matches the rest of
+// the topic; both must be used as their own segment, e.g. "sensors.+.temp"
+// or "logs.#". A concrete topic that matches the pattern is delivered to
+// subscriber the same way a literal Subscribe would be, the first time a
+// message is published on it.
+//
+// httprouter routes can't host "#" or "+" directly, so patterns are
+// passed in the request body instead of the URL. This is dispatched by
+// PubSub.postTopic rather than routed directly; see the reservedSubscribeTopic
+// comment on SetupRoutes.
+// POST /subscribe
+func (ps *PubSub) SubscribePattern(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var req subscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TopicPattern == "" || req.Subscriber == "" {
+		http.Error(w, "topic_pattern and subscriber are required", http.StatusBadRequest)
+		return
+	}
+
+	if !ps.authorize(w, r, req.TopicPattern, "subscribe_pattern", "") {
+		return
+	}
+
+	ps.Lock()
+	ps.wildcards.insert(req.TopicPattern, req.Subscriber)
+	ps.Unlock()
+
+	if err := ps.store.Subscribe(req.TopicPattern, req.Subscriber); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// UnsubscribePattern removes subscriber's subscription to a topic
+// pattern registered with SubscribePattern. Concrete subscriptions
+// already materialized by a matching publish aren't affected; unsubscribe
+// those with the literal DELETE /:topic_name/:subscriber_name route.
+//
+// Unlike SubscribePattern, subscriber comes from the URL rather than the
+// body: it's dispatched by PubSub.deleteTopic off the same
+// ":topic_name/:subscriber_name" route Unsubscribe uses (see the
+// reservedSubscribeTopic comment on SetupRoutes), which already carries a
+// subscriber segment.
+// DELETE /subscribe/:subscriber_name
+func (ps *PubSub) UnsubscribePattern(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	subscriber := p.ByName("subscriber_name")
+
+	var req subscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TopicPattern == "" {
+		http.Error(w, "topic_pattern is required", http.StatusBadRequest)
+		return
+	}
+
+	if !ps.authorize(w, r, req.TopicPattern, "subscribe_pattern", "") {
+		return
+	}
+
+	ps.Lock()
+	ps.wildcards.remove(req.TopicPattern, subscriber)
+	ps.Unlock()
+
+	if err := ps.store.Unsubscribe(req.TopicPattern, subscriber); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestSubscribePatternDeliversOnceForOverlappingPatterns(t *testing.T) {
+	ps := New()
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/subscribe", `{"topic_pattern": "sensors.#", "subscriber": "alice"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("subscribing to sensors.#, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("POST", "/subscribe", `{"topic_pattern": "sensors.+.temp", "subscriber": "alice"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("subscribing to sensors.+.temp, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("POST", fmt.Sprintf("/%s", "sensors.livingroom.temp"), `{"message": "21C"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publishing, expecting status %d, got %d and body %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	rec = do("GET", fmt.Sprintf("/%s/%s", "sensors.livingroom.temp", "alice"), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("polling, expecting status %d, got %d and body %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	var msgs []Message
+	if err := json.Unmarshal(rec.Body.Bytes(), &msgs); err != nil {
+		t.Fatalf("unmarshaling messages, got error %s", err)
+	}
+	if len(msgs) != 1 {
+		t.Errorf("polling after overlapping pattern subscriptions, expecting exactly 1 message, got %d", len(msgs))
+	}
+}
+
+func TestUnsubscribeConcreteTopicStaysUnsubscribedAfterWildcardMatch(t *testing.T) {
+	ps := New()
+	router := httprouter.New()
+	router = ps.SetupRoutes(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/subscribe", `{"topic_pattern": "sensors.#", "subscriber": "alice"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("subscribing to sensors.#, expecting status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	rec = do("POST", fmt.Sprintf("/%s", "sensors.livingroom.temp"), `{"message": "21C"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("publishing, expecting status %d, got %d and body %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	rec = do("DELETE", fmt.Sprintf("/%s/%s", "sensors.livingroom.temp", "alice"), "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("unsubscribing from the materialized topic, expecting status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	rec = do("POST", fmt.Sprintf("/%s", "sensors.livingroom.temp"), `{"message": "22C"}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("publishing after unsubscribe, expecting status %d (no subscribers), got %d", http.StatusNoContent, rec.Code)
+	}
+
+	rec = do("GET", fmt.Sprintf("/%s/%s", "sensors.livingroom.temp", "alice"), "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("polling after unsubscribing from a wildcard-matched topic, expecting alice to stay unsubscribed with status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
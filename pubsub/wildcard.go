@@ -0,0 +1,128 @@
+package pubsub
+
+import "strings"
+
+// splitTopic splits a hierarchical topic name or pattern into its
+// segments. Both "." and "/" are accepted as separators.
+func splitTopic(topic string) []string {
+	topic = strings.ReplaceAll(topic, "/", ".")
+	return strings.Split(topic, ".")
+}
+
+// topicTrie indexes wildcard subscription patterns so a published topic
+// can be matched against every registered pattern in O(depth) instead of
+// scanning them all. Patterns use the MQTT convention: "+" matches
+// exactly one segment, "#" matches the rest of the topic and must be the
+// last segment.
+type topicTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	// subscribers is set on the node a pattern terminates at.
+	subscribers map[string]struct{}
+	// tailSubscribers is set on the node preceding a trailing "#" and
+	// matches that node's topic plus anything below it.
+	tailSubscribers map[string]struct{}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children:        make(map[string]*trieNode),
+		subscribers:     make(map[string]struct{}),
+		tailSubscribers: make(map[string]struct{}),
+	}
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: newTrieNode()}
+}
+
+// insert registers subscriber against pattern.
+func (t *topicTrie) insert(pattern, subscriber string) {
+	node := t.root
+	segments := splitTopic(pattern)
+
+	for _, seg := range segments {
+		if seg == "#" {
+			node.tailSubscribers[subscriber] = struct{}{}
+			return
+		}
+
+		child, ok := node.children[seg]
+		if !ok {
+			child = newTrieNode()
+			node.children[seg] = child
+		}
+		node = child
+	}
+
+	node.subscribers[subscriber] = struct{}{}
+}
+
+// remove unregisters subscriber from pattern.
+func (t *topicTrie) remove(pattern, subscriber string) {
+	node := t.root
+	segments := splitTopic(pattern)
+
+	for _, seg := range segments {
+		if seg == "#" {
+			delete(node.tailSubscribers, subscriber)
+			return
+		}
+
+		child, ok := node.children[seg]
+		if !ok {
+			return
+		}
+		node = child
+	}
+
+	delete(node.subscribers, subscriber)
+}
+
+// match returns the deduplicated set of subscribers whose pattern
+// matches topic.
+func (t *topicTrie) match(topic string) []string {
+	segments := splitTopic(topic)
+	seen := make(map[string]struct{})
+
+	var walk func(node *trieNode, idx int)
+	walk = func(node *trieNode, idx int) {
+		for name := range node.tailSubscribers {
+			seen[name] = struct{}{}
+		}
+
+		if idx == len(segments) {
+			for name := range node.subscribers {
+				seen[name] = struct{}{}
+			}
+			return
+		}
+
+		seg := segments[idx]
+		if child, ok := node.children[seg]; ok {
+			walk(child, idx+1)
+		}
+		if child, ok := node.children["+"]; ok {
+			walk(child, idx+1)
+		}
+	}
+	walk(t.root, 0)
+
+	matched := make([]string, 0, len(seen))
+	for name := range seen {
+		matched = append(matched, name)
+	}
+
+	return matched
+}
+
+// subscribeRequest is the body of POST /subscribe and
+// DELETE /subscribe/:subscriber_name. Subscriber is ignored by the
+// latter, which takes it from the URL instead.
+type subscribeRequest struct {
+	TopicPattern string `json:"topic_pattern"`
+	Subscriber   string `json:"subscriber"`
+}
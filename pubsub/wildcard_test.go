@@ -0,0 +1,57 @@
+package pubsub
+
+import "testing"
+
+func TestTopicTrieMatch(t *testing.T) {
+	trie := newTopicTrie()
+	trie.insert("sensors.+.temp", "alice")
+	trie.insert("logs.#", "bob")
+	trie.insert("sensors.livingroom.temp", "carol")
+
+	cases := []struct {
+		topic string
+		want  []string
+	}{
+		{"sensors.livingroom.temp", []string{"alice", "carol"}},
+		{"sensors.kitchen.temp", []string{"alice"}},
+		{"logs.app.error", []string{"bob"}},
+		{"logs", []string{"bob"}},
+		{"other.topic", []string{}},
+	}
+
+	for _, c := range cases {
+		got := trie.match(c.topic)
+		if !sameElements(got, c.want) {
+			t.Errorf("matching %q, expecting %v, got %v", c.topic, c.want, got)
+		}
+	}
+}
+
+func TestTopicTrieMatchDedupesOverlappingPatterns(t *testing.T) {
+	trie := newTopicTrie()
+	trie.insert("sensors.#", "alice")
+	trie.insert("sensors.+.temp", "alice")
+
+	got := trie.match("sensors.livingroom.temp")
+	if !sameElements(got, []string{"alice"}) {
+		t.Errorf("matching with overlapping patterns for the same subscriber, expecting [alice] once, got %v", got)
+	}
+}
+
+func sameElements(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	set := make(map[string]bool, len(want))
+	for _, w := range want {
+		set[w] = true
+	}
+	for _, g := range got {
+		if !set[g] {
+			return false
+		}
+	}
+
+	return true
+}